@@ -1,33 +1,88 @@
 package config
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
-	"log"
 	"os"
+	"time"
 )
 
 type Config struct {
-	Source    DatabaseConfig `json:"source"`
-	Target    DatabaseConfig `json:"target"`
-	BatchSize int            `json:"batchSize"`
-	Tables    []Table        `json:"tables"`
+	Source          DatabaseConfig `json:"source"`
+	Target          DatabaseConfig `json:"target"`
+	BatchSize       int            `json:"batchSize"`
+	Tables          []Table        `json:"tables"`
+	Timeouts        Timeouts       `json:"timeouts,omitempty"`
+	CheckpointTable string         `json:"checkpointTable,omitempty"`
 }
 
 type DatabaseConfig struct {
-	DSN    string `json:"dsn"`
-	Schema string `json:"schema"`
-	Driver string `json:"driver"`
+	DSN            string `json:"dsn"`
+	Schema         string `json:"schema"`
+	Driver         string `json:"driver"`
+	MigrationTable string `json:"migrationTable,omitempty"`
 }
 
 type Table struct {
 	Name         string   `json:"name"`
 	Columns      []string `json:"columns"`
 	Dependencies []string `json:"dependencies"`
+	KeyColumns   []string `json:"keyColumns,omitempty"`
 }
 
-func ReadConfig(configFile string) (Config, error) {
+// Timeouts controls how long individual queries, whole batches, and
+// database-level statements are allowed to run before they're cancelled,
+// plus the threshold above which a query is logged as slow.
+type Timeouts struct {
+	QueryTimeout       Duration `json:"queryTimeout,omitempty"`
+	BatchTimeout       Duration `json:"batchTimeout,omitempty"`
+	StatementTimeout   Duration `json:"statementTimeout,omitempty"`
+	SlowQueryThreshold Duration `json:"slowQueryThreshold,omitempty"`
+}
+
+// Duration is a time.Duration that unmarshals from either a JSON number of
+// nanoseconds or a duration string such as "30s".
+type Duration time.Duration
+
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	switch value := raw.(type) {
+	case float64:
+		*d = Duration(value)
+	case string:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("parsing duration %q: %w", value, err)
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration value %v", raw)
+	}
+
+	return nil
+}
+
+func ReadConfig(ctx context.Context, configFile string) (Config, error) {
 	config := Config{}
+
+	if err := ctx.Err(); err != nil {
+		return config, err
+	}
+
 	file, err := os.Open(configFile)
 	if err != nil {
 		return config, err
@@ -43,20 +98,25 @@ func ReadConfig(configFile string) (Config, error) {
 	return config, err
 }
 
-func WriteConfig(configPath string, config Config) {
+func WriteConfig(ctx context.Context, configPath string, config Config) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	configFile, err := os.Create(configPath)
 	if err != nil {
-		log.Fatalf("Error creating config file: %v", err)
+		return fmt.Errorf("creating config file: %w", err)
 	}
 	defer configFile.Close()
 
 	configBytes, err := json.MarshalIndent(config, "", "  ")
 	if err != nil {
-		log.Fatalf("Error marshalling config: %v", err)
+		return fmt.Errorf("marshalling config: %w", err)
 	}
 
-	_, err = configFile.Write(configBytes)
-	if err != nil {
-		log.Fatalf("Error writing to config file: %v", err)
+	if _, err := configFile.Write(configBytes); err != nil {
+		return fmt.Errorf("writing to config file: %w", err)
 	}
+
+	return nil
 }
@@ -0,0 +1,168 @@
+package functions
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/primatime/database-migrator/utils"
+)
+
+// DefaultCheckpointTable is used when Config.CheckpointTable is not set.
+const DefaultCheckpointTable = "migrator_checkpoints"
+
+// EnsureCheckpointTable creates the checkpoint tracking table if it doesn't
+// already exist.
+func EnsureCheckpointTable(ctx context.Context, db utils.Querier, driver, table string) error {
+	var ddl string
+
+	switch driver {
+	case "godror":
+		ddl = fmt.Sprintf(`
+			BEGIN
+				EXECUTE IMMEDIATE 'CREATE TABLE %s (
+					table_name VARCHAR2(255) PRIMARY KEY,
+					last_key VARCHAR2(4000),
+					rows_copied NUMBER,
+					updated_at TIMESTAMP
+				)';
+			EXCEPTION
+				WHEN OTHERS THEN
+					IF SQLCODE != -955 THEN
+						RAISE;
+					END IF;
+			END;`, table)
+	case "postgres":
+		ddl = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			table_name TEXT PRIMARY KEY,
+			last_key TEXT,
+			rows_copied BIGINT,
+			updated_at TIMESTAMP
+		)`, table)
+	default:
+		return fmt.Errorf("unsupported driver %q", driver)
+	}
+
+	if _, err := db.ExecContext(ctx, ddl); err != nil {
+		return fmt.Errorf("creating checkpoint table %s: %w", table, err)
+	}
+
+	return nil
+}
+
+// LoadCheckpoint returns the last recorded key tuple and row count for a
+// table, or found=false if no checkpoint exists yet.
+func LoadCheckpoint(ctx context.Context, db utils.Querier, driver, checkpointTable, tableName string) (lastKey []string, rowsCopied int64, found bool, err error) {
+	var lastKeyJSON string
+
+	row := db.QueryRowContext(ctx, fmt.Sprintf("SELECT last_key, rows_copied FROM %s WHERE table_name = %s", checkpointTable, placeholder(driver, 1)), tableName)
+	if scanErr := row.Scan(&lastKeyJSON, &rowsCopied); scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return nil, 0, false, nil
+		}
+		return nil, 0, false, fmt.Errorf("loading checkpoint for table %s: %w", tableName, scanErr)
+	}
+
+	if lastKeyJSON != "" {
+		if err := json.Unmarshal([]byte(lastKeyJSON), &lastKey); err != nil {
+			return nil, 0, false, fmt.Errorf("decoding checkpoint key for table %s: %w", tableName, err)
+		}
+	}
+
+	return lastKey, rowsCopied, true, nil
+}
+
+// ResetCheckpoints removes every recorded checkpoint, used when migrations
+// are restarted from scratch.
+func ResetCheckpoints(ctx context.Context, db utils.Querier, driver, checkpointTable string) error {
+	if err := EnsureCheckpointTable(ctx, db, driver, checkpointTable); err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s", checkpointTable)); err != nil {
+		return fmt.Errorf("resetting checkpoints in %s: %w", checkpointTable, err)
+	}
+
+	return nil
+}
+
+// checkpointUpdate carries the information a Loader needs to upsert a
+// table's checkpoint inside the same transaction as its data batch.
+type checkpointUpdate struct {
+	driver     string
+	table      string
+	tableName  string
+	lastKey    []string
+	rowsCopied int64
+}
+
+func (c *checkpointUpdate) apply(ctx context.Context, tx *sql.Tx) error {
+	if c == nil {
+		return nil
+	}
+
+	lastKeyJSON, err := json.Marshal(c.lastKey)
+	if err != nil {
+		return fmt.Errorf("encoding checkpoint key for table %s: %w", c.tableName, err)
+	}
+
+	switch c.driver {
+	case "postgres":
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(`
+			INSERT INTO %s (table_name, last_key, rows_copied, updated_at)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (table_name) DO UPDATE SET
+				last_key = EXCLUDED.last_key,
+				rows_copied = %s.rows_copied + EXCLUDED.rows_copied,
+				updated_at = EXCLUDED.updated_at`, c.table, c.table),
+			c.tableName, string(lastKeyJSON), c.rowsCopied, time.Now().UTC())
+	case "godror":
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(`
+			MERGE INTO %s t
+			USING (SELECT :1 AS table_name, :2 AS last_key, :3 AS rows_copied, :4 AS updated_at FROM dual) s
+			ON (t.table_name = s.table_name)
+			WHEN MATCHED THEN UPDATE SET
+				t.last_key = s.last_key,
+				t.rows_copied = t.rows_copied + s.rows_copied,
+				t.updated_at = s.updated_at
+			WHEN NOT MATCHED THEN
+				INSERT (table_name, last_key, rows_copied, updated_at)
+				VALUES (s.table_name, s.last_key, s.rows_copied, s.updated_at)`, c.table),
+			c.tableName, string(lastKeyJSON), c.rowsCopied, time.Now().UTC())
+	default:
+		return fmt.Errorf("unsupported driver %q", c.driver)
+	}
+
+	if err != nil {
+		return fmt.Errorf("updating checkpoint for table %s: %w", c.tableName, err)
+	}
+
+	return nil
+}
+
+// placeholder renders the positional parameter marker for driver: $pos for
+// postgres and :pos for godror.
+func placeholder(driver string, pos int) string {
+	switch driver {
+	case "postgres":
+		return "$" + strconv.Itoa(pos)
+	case "godror":
+		return ":" + strconv.Itoa(pos)
+	default:
+		// Any other driver name gets a bare "?", which neither supported
+		// driver accepts as a parameter marker.
+		return "?"
+	}
+}
+
+func keyPlaceholders(driver string, count int) string {
+	placeholders := make([]string, count)
+	for i := 0; i < count; i++ {
+		placeholders[i] = placeholder(driver, i+1)
+	}
+	return strings.Join(placeholders, ", ")
+}
@@ -0,0 +1,252 @@
+package functions
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/primatime/database-migrator/utils"
+)
+
+// Loader bulk-loads a batch of already-scanned rows into a target table
+// using the fastest mechanism the target driver offers, instead of building
+// a single large string-interpolated INSERT statement. When checkpoint is
+// non-nil, the loader records the batch's progress in the same transaction
+// as the data it loads, so a crash can never leave the two out of sync.
+type Loader interface {
+	Load(ctx context.Context, targetDB utils.TxQuerier, schema, table string, columns []string, rows [][]interface{}, checkpoint *checkpointUpdate) (int64, error)
+}
+
+// LoaderFor returns the Loader implementation for the given driver name.
+func LoaderFor(driver string) (Loader, error) {
+	switch driver {
+	case "postgres":
+		return PostgresLoader{}, nil
+	case "godror":
+		return OracleLoader{}, nil
+	default:
+		return nil, fmt.Errorf("no bulk loader available for driver %q", driver)
+	}
+}
+
+// PostgresLoader streams rows into the target table with COPY FROM STDIN
+// via pq.CopyIn, inside a single transaction per batch.
+type PostgresLoader struct{}
+
+func (PostgresLoader) Load(ctx context.Context, targetDB utils.TxQuerier, schema, table string, columns []string, rows [][]interface{}, checkpoint *checkpointUpdate) (int64, error) {
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	tx, err := targetDB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("starting copy transaction for table %s: %w", table, err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyInSchema(schema, table, columns...))
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("preparing COPY for table %s: %w", table, err)
+	}
+
+	for _, row := range rows {
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
+			stmt.Close()
+			tx.Rollback()
+			return 0, fmt.Errorf("copying row into table %s: %w", table, err)
+		}
+	}
+
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		stmt.Close()
+		tx.Rollback()
+		return 0, fmt.Errorf("flushing COPY for table %s: %w", table, err)
+	}
+
+	if err := stmt.Close(); err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("closing COPY statement for table %s: %w", table, err)
+	}
+
+	if err := checkpoint.apply(ctx, tx); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing copy for table %s: %w", table, err)
+	}
+
+	return int64(len(rows)), nil
+}
+
+// OracleLoader inserts rows with a single array-bind ExecContext call per
+// batch, which godror turns into one OCI array insert.
+type OracleLoader struct{}
+
+func (OracleLoader) Load(ctx context.Context, targetDB utils.TxQuerier, schema, table string, columns []string, rows [][]interface{}, checkpoint *checkpointUpdate) (int64, error) {
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	tx, err := targetDB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("starting array-insert transaction for table %s: %w", table, err)
+	}
+
+	columnSlices := make([]interface{}, len(columns))
+	for col := range columns {
+		columnSlices[col] = bindSlice(rows, col)
+	}
+
+	placeholders := make([]string, len(columns))
+	for i, column := range columns {
+		placeholders[i] = ":" + column
+	}
+
+	insertQuery := fmt.Sprintf("INSERT INTO %s.%s (%s) VALUES (%s)", schema, table, utils.JoinColumns(columns), utils.JoinColumns(placeholders))
+
+	if _, err := tx.ExecContext(ctx, insertQuery, columnSlices...); err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("array-inserting batch into table %s: %w", table, err)
+	}
+
+	if err := checkpoint.apply(ctx, tx); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing array insert for table %s: %w", table, err)
+	}
+
+	return int64(len(rows)), nil
+}
+
+// scanDestination returns a typed, nil-able pointer to scan a column of the
+// given driver type into, instead of a bare interface{} destined for
+// fmt.Sprint.
+func scanDestination(colType *sql.ColumnType) interface{} {
+	switch colType.DatabaseTypeName() {
+	case "BOOL", "BOOLEAN":
+		return new(sql.NullBool)
+	case "INT2", "INT4", "INT8", "INTEGER":
+		return new(sql.NullInt64)
+	case "FLOAT4", "FLOAT8", "NUMERIC", "DECIMAL", "NUMBER":
+		return new(sql.NullFloat64)
+	case "DATE", "TIMESTAMP", "TIMESTAMPTZ":
+		return new(sql.NullTime)
+	case "BYTEA", "RAW", "BLOB":
+		return new([]byte)
+	default:
+		return new(sql.NullString)
+	}
+}
+
+// scanValue unwraps a scanDestination pointer back into a plain value (or
+// nil), suitable for passing straight to a Loader.
+func scanValue(dest interface{}) interface{} {
+	switch v := dest.(type) {
+	case *sql.NullBool:
+		if v.Valid {
+			return v.Bool
+		}
+		return nil
+	case *sql.NullInt64:
+		if v.Valid {
+			return v.Int64
+		}
+		return nil
+	case *sql.NullFloat64:
+		if v.Valid {
+			return v.Float64
+		}
+		return nil
+	case *sql.NullTime:
+		if v.Valid {
+			return v.Time
+		}
+		return nil
+	case *sql.NullString:
+		if v.Valid {
+			return v.String
+		}
+		return nil
+	case *[]byte:
+		return *v
+	default:
+		return v
+	}
+}
+
+// bindSlice builds the per-column array godror binds: a slice of a single
+// concrete Go type (plus a Null wrapper so individual rows can still be
+// NULL), never []interface{}, which godror's bindVarTypeSwitch has no case
+// for and rejects. The concrete type is taken from the first non-nil value
+// scanValue produced for this column; columns that are entirely NULL fall
+// back to []sql.NullString.
+func bindSlice(rows [][]interface{}, col int) interface{} {
+	var sample interface{}
+	for _, row := range rows {
+		if row[col] != nil {
+			sample = row[col]
+			break
+		}
+	}
+
+	switch sample.(type) {
+	case bool:
+		slice := make([]sql.NullBool, len(rows))
+		for i, row := range rows {
+			if v, ok := row[col].(bool); ok {
+				slice[i] = sql.NullBool{Bool: v, Valid: true}
+			}
+		}
+		return slice
+	case int64:
+		slice := make([]sql.NullInt64, len(rows))
+		for i, row := range rows {
+			if v, ok := row[col].(int64); ok {
+				slice[i] = sql.NullInt64{Int64: v, Valid: true}
+			}
+		}
+		return slice
+	case float64:
+		slice := make([]sql.NullFloat64, len(rows))
+		for i, row := range rows {
+			if v, ok := row[col].(float64); ok {
+				slice[i] = sql.NullFloat64{Float64: v, Valid: true}
+			}
+		}
+		return slice
+	case time.Time:
+		slice := make([]sql.NullTime, len(rows))
+		for i, row := range rows {
+			if v, ok := row[col].(time.Time); ok {
+				slice[i] = sql.NullTime{Time: v, Valid: true}
+			}
+		}
+		return slice
+	case []byte:
+		slice := make([][]byte, len(rows))
+		for i, row := range rows {
+			if v, ok := row[col].([]byte); ok {
+				slice[i] = v
+			}
+		}
+		return slice
+	default:
+		slice := make([]sql.NullString, len(rows))
+		for i, row := range rows {
+			v, ok := row[col].(string)
+			if !ok {
+				continue
+			}
+			slice[i] = sql.NullString{String: v, Valid: true}
+		}
+		return slice
+	}
+}
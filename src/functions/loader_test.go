@@ -0,0 +1,132 @@
+package functions
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestBindSliceDispatchesOnConcreteType(t *testing.T) {
+	ts := time.Unix(1_700_000_000, 0).UTC()
+
+	tests := []struct {
+		name string
+		rows [][]interface{}
+		col  int
+		want interface{}
+	}{
+		{
+			name: "bool",
+			rows: [][]interface{}{{true}, {nil}, {false}},
+			col:  0,
+			want: []sql.NullBool{{Bool: true, Valid: true}, {}, {Bool: false, Valid: true}},
+		},
+		{
+			name: "int64",
+			rows: [][]interface{}{{int64(1)}, {nil}, {int64(3)}},
+			col:  0,
+			want: []sql.NullInt64{{Int64: 1, Valid: true}, {}, {Int64: 3, Valid: true}},
+		},
+		{
+			name: "float64",
+			rows: [][]interface{}{{nil}, {1.5}},
+			col:  0,
+			want: []sql.NullFloat64{{}, {Float64: 1.5, Valid: true}},
+		},
+		{
+			name: "time",
+			rows: [][]interface{}{{ts}, {nil}},
+			col:  0,
+			want: []sql.NullTime{{Time: ts, Valid: true}, {}},
+		},
+		{
+			name: "bytes",
+			rows: [][]interface{}{{[]byte("a")}, {nil}},
+			col:  0,
+			want: [][]byte{[]byte("a"), nil},
+		},
+		{
+			name: "string",
+			rows: [][]interface{}{{"a"}, {nil}, {"b"}},
+			col:  0,
+			want: []sql.NullString{{String: "a", Valid: true}, {}, {String: "b", Valid: true}},
+		},
+		{
+			name: "all nil falls back to NullString",
+			rows: [][]interface{}{{nil}, {nil}},
+			col:  0,
+			want: []sql.NullString{{}, {}},
+		},
+		{
+			name: "selects the right column out of a multi-column row",
+			rows: [][]interface{}{{int64(1), "x"}, {int64(2), "y"}},
+			col:  1,
+			want: []sql.NullString{{String: "x", Valid: true}, {String: "y", Valid: true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := bindSlice(tt.rows, tt.col)
+
+			gotLen := sliceLen(t, got)
+			wantLen := sliceLen(t, tt.want)
+			if gotLen != wantLen {
+				t.Fatalf("bindSlice(%v, %d) = %#v, want %#v", tt.rows, tt.col, got, tt.want)
+			}
+
+			switch want := tt.want.(type) {
+			case []sql.NullBool:
+				assertEqual(t, got.([]sql.NullBool), want)
+			case []sql.NullInt64:
+				assertEqual(t, got.([]sql.NullInt64), want)
+			case []sql.NullFloat64:
+				assertEqual(t, got.([]sql.NullFloat64), want)
+			case []sql.NullTime:
+				assertEqual(t, got.([]sql.NullTime), want)
+			case []sql.NullString:
+				assertEqual(t, got.([]sql.NullString), want)
+			case [][]byte:
+				gotBytes := got.([][]byte)
+				for i := range want {
+					if string(gotBytes[i]) != string(want[i]) {
+						t.Fatalf("bindSlice[%d] = %q, want %q", i, gotBytes[i], want[i])
+					}
+				}
+			}
+		})
+	}
+}
+
+func sliceLen(t *testing.T, v interface{}) int {
+	t.Helper()
+	switch s := v.(type) {
+	case []sql.NullBool:
+		return len(s)
+	case []sql.NullInt64:
+		return len(s)
+	case []sql.NullFloat64:
+		return len(s)
+	case []sql.NullTime:
+		return len(s)
+	case []sql.NullString:
+		return len(s)
+	case [][]byte:
+		return len(s)
+	default:
+		t.Fatalf("unexpected type %T", v)
+		return 0
+	}
+}
+
+func assertEqual[T comparable](t *testing.T, got, want []T) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("length mismatch: got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("index %d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+}
@@ -1,9 +1,9 @@
 package functions
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -13,110 +13,233 @@ import (
 	"github.com/primatime/database-migrator/utils"
 )
 
-func MigrateTable(sourceDB, targetDB *sql.DB, table Table, batchSize int, progressData progress.Data, sourceDriver string, sourceSchema string, targetSchema string) {
+// MigrateTable copies table in batches from sourceDB to targetDB using
+// keyset pagination over table.KeyColumns, checkpointing its progress after
+// every batch so a killed run can resume instead of restarting at row zero.
+func MigrateTable(ctx context.Context, sourceDB utils.Querier, targetDB utils.TxQuerier, table Table, batchSize int, progressData progress.Data, sourceDriver, sourceSchema, targetDriver, targetSchema, checkpointTable string, resume bool, timeouts Timeouts) error {
+	if len(table.KeyColumns) == 0 {
+		return fmt.Errorf("table %s has no key columns; resumable migration requires a primary key", table.Name)
+	}
+
 	columns := table.Columns
-	columnsJoined := utils.JoinColumns(columns)
+	keyIndexes := make([]int, len(table.KeyColumns))
+	for i, keyColumn := range table.KeyColumns {
+		index := indexOf(columns, keyColumn)
+		if index < 0 {
+			return fmt.Errorf("table %s: key column %s is not among the selected columns", table.Name, keyColumn)
+		}
+		keyIndexes[i] = index
+	}
 
-	startTime := time.Now()
+	loader, err := LoaderFor(targetDriver)
+	if err != nil {
+		return err
+	}
 
-	// Prepare the insert statement for target database
-	insertStmt := fmt.Sprintf("INSERT INTO %s.%s (%s) VALUES", targetSchema, table.Name, columnsJoined) + " %s"
+	if err := EnsureCheckpointTable(ctx, targetDB, targetDriver, checkpointTable); err != nil {
+		return err
+	}
+
+	var lastKey []string
+	if resume {
+		key, _, found, err := LoadCheckpoint(ctx, targetDB, targetDriver, checkpointTable, table.Name)
+		if err != nil {
+			return err
+		}
+		if found {
+			lastKey = key
+		}
+	}
+
+	startTime := time.Now()
 
 	// Start progress display for this table
 	progressTicker := time.NewTicker(1 * time.Second)
 	defer progressTicker.Stop()
 
+	stopProgress := make(chan struct{})
+	defer close(stopProgress)
+
 	go func() {
-		for range progressTicker.C {
-			progress.Print(table.Name, progressData, startTime)
+		for {
+			select {
+			case <-progressTicker.C:
+				progress.Print(table.Name, progressData, startTime)
+			case <-stopProgress:
+				return
+			}
 		}
 	}()
 
-	var offset int64 = 0
+	var colTypes []*sql.ColumnType
 	for {
-		// Retrieve data from source using pagination
-		var query string
-
-		switch sourceDriver {
-		case "godror":
-			query = fmt.Sprintf(`
-					SELECT %s FROM (
-						SELECT t.*, ROWNUM rnum FROM (
-							SELECT %s FROM %s.%s
-						) t
-						WHERE ROWNUM <= %d
-					)
-					WHERE rnum > %d`, columnsJoined, columnsJoined, sourceSchema, table.Name, offset+int64(batchSize), offset)
-		case "postgres":
-			query = fmt.Sprintf(`
-					SELECT %s FROM (
-						SELECT t.*, ROW_NUMBER() OVER () AS rnum FROM (
-							SELECT %s FROM %s.%s
-						) t
-					) AS subquery
-					WHERE rnum <= %d
-					OFFSET %d`, columnsJoined, columnsJoined, sourceSchema, table.Name, offset+int64(batchSize), offset)
+		batchCtx, cancel := withOptionalTimeout(ctx, timeouts.BatchTimeout.Duration())
+		rowBatch, newLastKey, err := migrateBatch(batchCtx, sourceDB, targetDB, loader, table, columns, table.KeyColumns, keyIndexes, batchSize, lastKey, progressData, sourceDriver, sourceSchema, targetDriver, targetSchema, checkpointTable, &colTypes, timeouts.QueryTimeout.Duration())
+		cancel()
+		if err != nil {
+			return err
 		}
 
-		rows, err := sourceDB.Query(query)
-		if err != nil {
-			log.Fatalf("Error querying Source database for table %s: %v", table.Name, err)
+		if rowBatch > 0 {
+			lastKey = newLastKey
 		}
 
-		values := make([]string, 0, len(columns)*batchSize)
-		rowBatch := 0
+		if rowBatch < batchSize {
+			break
+		}
+	}
 
-		for rows.Next() {
-			columnValues := make([]interface{}, len(columns))
-			columnPointers := make([]interface{}, len(columns))
+	progress.Print(table.Name, progressData, startTime) // print the final progress
+	return nil
+}
 
-			for i := range columns {
-				columnPointers[i] = &columnValues[i]
-			}
+func migrateBatch(ctx context.Context, sourceDB utils.Querier, targetDB utils.TxQuerier, loader Loader, table Table, columns []string, keyColumns []string, keyIndexes []int, batchSize int, lastKey []string, progressData progress.Data, sourceDriver, sourceSchema, targetDriver, targetSchema, checkpointTable string, colTypes *[]*sql.ColumnType, queryTimeout time.Duration) (int, []string, error) {
+	query, args := keysetQuery(sourceDriver, columns, keyColumns, sourceSchema, table.Name, lastKey, batchSize)
 
-			err = rows.Scan(columnPointers...)
-			if err != nil {
-				log.Fatalf("Error scanning row from table %s: %v", table.Name, err)
-			}
+	queryCtx, cancel := withOptionalTimeout(ctx, queryTimeout)
+	defer cancel()
 
-			stringValues := make([]string, 0)
-			for _, v := range columnValues {
-				if v == nil {
-					stringValues = append(stringValues, "NULL")
-				} else if t, ok := v.(time.Time); ok {
-					stringValues = append(stringValues, t.UTC().Format("'2006-01-02 15:04:05.00 +00:00'"))
-				} else {
-					stringValues = append(stringValues, fmt.Sprintf("'%s'", fmt.Sprint(v)))
-				}
-			}
+	rows, err := sourceDB.QueryContext(queryCtx, query, args...)
+	if err != nil {
+		return 0, nil, fmt.Errorf("querying source database for table %s: %w", table.Name, err)
+	}
+	defer rows.Close()
 
-			values = append(values, fmt.Sprintf("(%s)", utils.JoinColumns(stringValues)))
-			rowBatch++
+	if *colTypes == nil {
+		types, err := rows.ColumnTypes()
+		if err != nil {
+			return 0, nil, fmt.Errorf("reading column types for table %s: %w", table.Name, err)
 		}
+		*colTypes = types
+	}
 
-		if rowBatch > 0 {
-			insertQuery := fmt.Sprintf(insertStmt, strings.Join(values, ", "))
-			_, err = targetDB.Exec(insertQuery)
-			if err != nil {
-				log.Fatalf("Error inserting batch into table %s: %v", table.Name, err)
-			}
+	batch := make([][]interface{}, 0, batchSize)
 
-			atomic.AddInt64(&progressData[table.Name].Migrated, int64(rowBatch))
+	for rows.Next() {
+		dest := make([]interface{}, len(columns))
+		for i, colType := range *colTypes {
+			dest[i] = scanDestination(colType)
 		}
 
-		rows.Close()
+		if err := rows.Scan(dest...); err != nil {
+			return 0, nil, fmt.Errorf("scanning row from table %s: %w", table.Name, err)
+		}
 
-		if err = rows.Err(); err != nil {
-			log.Fatalf("Error iterating through rows for table %s: %v", table.Name, err)
+		row := make([]interface{}, len(columns))
+		for i, d := range dest {
+			row[i] = scanValue(d)
 		}
 
-		if rowBatch < batchSize {
-			break
+		batch = append(batch, row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return 0, nil, fmt.Errorf("iterating through rows for table %s: %w", table.Name, err)
+	}
+
+	rowBatch := len(batch)
+	if rowBatch == 0 {
+		return 0, nil, nil
+	}
+
+	newLastKey := make([]string, len(keyIndexes))
+	lastRow := batch[rowBatch-1]
+	for i, keyIndex := range keyIndexes {
+		newLastKey[i] = fmt.Sprint(lastRow[keyIndex])
+	}
+
+	checkpoint := &checkpointUpdate{
+		driver:     targetDriver,
+		table:      checkpointTable,
+		tableName:  table.Name,
+		lastKey:    newLastKey,
+		rowsCopied: int64(rowBatch),
+	}
+
+	loaded, err := loader.Load(ctx, targetDB, targetSchema, table.Name, columns, batch, checkpoint)
+	if err != nil {
+		return 0, nil, fmt.Errorf("loading batch into table %s: %w", table.Name, err)
+	}
+
+	atomic.AddInt64(&progressData[table.Name].Migrated, loaded)
+
+	return rowBatch, newLastKey, nil
+}
+
+// keysetQuery builds a keyset-paginated SELECT: rows are ordered by the
+// table's key columns, and only rows after lastKey are returned, avoiding
+// the re-sort-the-whole-table cost of ROWNUM/ROW_NUMBER() OVER () offset
+// pagination. It returns the query alongside the bind args in the order
+// they appear in it, since the Oracle expansion in keysetWhere can bind the
+// same lastKey value more than once.
+func keysetQuery(driver string, columns, keyColumns []string, schema, tableName string, lastKey []string, batchSize int) (string, []interface{}) {
+	columnsJoined := utils.JoinColumns(columns)
+	keyColumnsJoined := utils.JoinColumns(keyColumns)
+
+	where := ""
+	var args []interface{}
+	if len(lastKey) > 0 {
+		var whereExpr string
+		whereExpr, args = keysetWhere(driver, keyColumns, lastKey)
+		where = "WHERE " + whereExpr
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s FROM %s.%s
+		%s
+		ORDER BY %s
+		FETCH NEXT %d ROWS ONLY`, columnsJoined, schema, tableName, where, keyColumnsJoined, batchSize)
+
+	return query, args
+}
+
+// keysetWhere builds the "further than lastKey" predicate. Postgres supports
+// row-value comparison directly, so a single-column key and every Postgres
+// key use (keyCols...) > (lastKey...). Oracle rejects `<`/`>`/`<=`/`>=`
+// between a column list and a value list (ORA-01796) for more than one
+// column, so a composite godror key is expanded into the equivalent OR-chain
+// of prefix-equality plus a final strict inequality:
+// (k0 > v0) OR (k0 = v0 AND k1 > v1) OR ...
+func keysetWhere(driver string, keyColumns, lastKey []string) (string, []interface{}) {
+	if driver != "godror" || len(keyColumns) == 1 {
+		args := make([]interface{}, len(lastKey))
+		for i, v := range lastKey {
+			args[i] = v
 		}
+		return fmt.Sprintf("(%s) > (%s)", utils.JoinColumns(keyColumns), keyPlaceholders(driver, len(lastKey))), args
+	}
 
-		offset += int64(batchSize)
+	var args []interface{}
+	clauses := make([]string, len(keyColumns))
+	pos := 0
+	for i := range keyColumns {
+		conjuncts := make([]string, i+1)
+		for j := 0; j < i; j++ {
+			pos++
+			conjuncts[j] = fmt.Sprintf("%s = %s", keyColumns[j], placeholder(driver, pos))
+			args = append(args, lastKey[j])
+		}
+		pos++
+		conjuncts[i] = fmt.Sprintf("%s > %s", keyColumns[i], placeholder(driver, pos))
+		args = append(args, lastKey[i])
+		clauses[i] = "(" + strings.Join(conjuncts, " AND ") + ")"
 	}
 
-	progressTicker.Stop()                               // stop the progress ticker
-	progress.Print(table.Name, progressData, startTime) // print the final progress
+	return strings.Join(clauses, " OR "), args
+}
+
+func indexOf(values []string, target string) int {
+	for i, v := range values {
+		if v == target {
+			return i
+		}
+	}
+	return -1
+}
+
+func withOptionalTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
 }
@@ -0,0 +1,66 @@
+package functions
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKeysetWherePostgresUsesRowValueComparison(t *testing.T) {
+	expr, args := keysetWhere("postgres", []string{"a", "b"}, []string{"1", "2"})
+
+	if want := "(a, b) > ($1, $2)"; expr != want {
+		t.Fatalf("expr = %q, want %q", expr, want)
+	}
+	if len(args) != 2 || args[0] != "1" || args[1] != "2" {
+		t.Fatalf("args = %v, want [1 2]", args)
+	}
+}
+
+func TestKeysetWhereGodrorSingleColumnUsesRowValueComparison(t *testing.T) {
+	expr, args := keysetWhere("godror", []string{"a"}, []string{"1"})
+
+	if want := "(a) > (:1)"; expr != want {
+		t.Fatalf("expr = %q, want %q", expr, want)
+	}
+	if len(args) != 1 || args[0] != "1" {
+		t.Fatalf("args = %v, want [1]", args)
+	}
+}
+
+// TestKeysetWhereGodrorCompositeKeyAvoidsRowValueComparison guards against
+// ORA-01796: Oracle rejects a <, >, <=, >= comparison between a column list
+// and a value list once more than one column is involved, so a composite
+// godror key must expand into an OR-chain instead.
+func TestKeysetWhereGodrorCompositeKeyAvoidsRowValueComparison(t *testing.T) {
+	expr, args := keysetWhere("godror", []string{"a", "b", "c"}, []string{"1", "2", "3"})
+
+	if strings.Contains(expr, ") > (") {
+		t.Fatalf("expr = %q uses a row-value comparison, which Oracle rejects for composite keys", expr)
+	}
+
+	want := "(a > :1) OR (a = :2 AND b > :3) OR (a = :4 AND b = :5 AND c > :6)"
+	if expr != want {
+		t.Fatalf("expr = %q, want %q", expr, want)
+	}
+
+	wantArgs := []string{"1", "1", "2", "1", "2", "3"}
+	if len(args) != len(wantArgs) {
+		t.Fatalf("args = %v, want %v", args, wantArgs)
+	}
+	for i, want := range wantArgs {
+		if args[i] != want {
+			t.Fatalf("args[%d] = %v, want %v", i, args[i], want)
+		}
+	}
+}
+
+func TestKeysetQueryOmitsWhereOnFirstPage(t *testing.T) {
+	query, args := keysetQuery("godror", []string{"a", "b"}, []string{"a"}, "schema", "table", nil, 100)
+
+	if strings.Contains(query, "WHERE") {
+		t.Fatalf("query = %q, expected no WHERE clause for an empty lastKey", query)
+	}
+	if len(args) != 0 {
+		t.Fatalf("args = %v, want none", args)
+	}
+}
@@ -0,0 +1,247 @@
+package functions
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/primatime/database-migrator/config"
+	"github.com/primatime/database-migrator/utils"
+)
+
+// DefaultPlanThroughput is the rows/second estimate used when no checkpoint
+// history is available to derive one.
+const DefaultPlanThroughput = 1000.0
+
+// TableStatus summarizes how far a table's migration has progressed,
+// relative to the source row count.
+type TableStatus string
+
+const (
+	StatusPending  TableStatus = "pending"
+	StatusPartial  TableStatus = "partial"
+	StatusComplete TableStatus = "complete"
+	StatusBlocked  TableStatus = "blocked"
+)
+
+// PlanEntry is one row of the dry-run plan report.
+type PlanEntry struct {
+	Table         string
+	SourceRows    int64
+	TargetRows    int64
+	Dependencies  []string
+	Status        TableStatus
+	EstimatedTime time.Duration
+}
+
+// Plan is the full dry-run report: a per-table breakdown plus the waves of
+// tables that filterTables will actually schedule concurrently.
+type Plan struct {
+	Entries []PlanEntry
+	Waves   [][]string
+}
+
+// AllComplete reports whether every table in the plan is already fully
+// migrated, making the plan usable as a CI gate.
+func (p Plan) AllComplete() bool {
+	for _, entry := range p.Entries {
+		if entry.Status != StatusComplete {
+			return false
+		}
+	}
+	return true
+}
+
+// BuildPlan inspects source and target row counts plus checkpoint history
+// to describe what a migration run would do, without copying any data.
+func BuildPlan(ctx context.Context, sourceDB, targetDB utils.Querier, config Config, checkpointTable string) (Plan, error) {
+	if err := EnsureCheckpointTable(ctx, targetDB, config.Target.Driver, checkpointTable); err != nil {
+		return Plan{}, err
+	}
+
+	checkpoints, err := listCheckpoints(ctx, targetDB, checkpointTable)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	throughput, ok := observedThroughput(checkpoints)
+	if !ok {
+		throughput = DefaultPlanThroughput
+	}
+
+	known := make(map[string]bool, len(config.Tables))
+	for _, table := range config.Tables {
+		known[table.Name] = true
+	}
+
+	entries := make([]PlanEntry, 0, len(config.Tables))
+	for _, table := range config.Tables {
+		sourceRows, err := utils.GetRowCount(ctx, sourceDB, table.Name, config.Source.Schema)
+		if err != nil {
+			return Plan{}, err
+		}
+
+		targetRows, err := utils.GetRowCount(ctx, targetDB, table.Name, config.Target.Schema)
+		if err != nil {
+			return Plan{}, err
+		}
+
+		status := StatusPending
+		switch {
+		case !dependenciesSatisfiable(table, known):
+			status = StatusBlocked
+		case targetRows >= sourceRows && sourceRows > 0:
+			status = StatusComplete
+		case sourceRows == 0 && targetRows == 0:
+			status = StatusComplete
+		case targetRows > 0:
+			status = StatusPartial
+		}
+
+		var estimatedTime time.Duration
+		if status == StatusPending || status == StatusPartial {
+			remaining := sourceRows - targetRows
+			if remaining > 0 {
+				estimatedTime = time.Duration(float64(remaining)/throughput) * time.Second
+			}
+		}
+
+		entries = append(entries, PlanEntry{
+			Table:         table.Name,
+			SourceRows:    sourceRows,
+			TargetRows:    targetRows,
+			Dependencies:  table.Dependencies,
+			Status:        status,
+			EstimatedTime: estimatedTime,
+		})
+	}
+
+	return Plan{Entries: entries, Waves: computeWaves(config.Tables)}, nil
+}
+
+// Print writes the plan as a simple aligned table, mirroring the format
+// migrations.PrintStatus uses for schema migrations.
+func (p Plan) Print() {
+	fmt.Printf("%-24s %-14s %-14s %-30s %-10s %s\n", "TABLE", "ROWS(SOURCE)", "ROWS(TARGET)", "DEPENDENCIES", "STATUS", "EST. TIME")
+	for _, entry := range p.Entries {
+		deps := "-"
+		if len(entry.Dependencies) > 0 {
+			deps = utils.JoinColumns(entry.Dependencies)
+		}
+
+		estimate := "-"
+		if entry.EstimatedTime > 0 {
+			estimate = entry.EstimatedTime.String()
+		}
+
+		fmt.Printf("%-24s %-14d %-14d %-30s %-10s %s\n", entry.Table, entry.SourceRows, entry.TargetRows, deps, entry.Status, estimate)
+	}
+
+	fmt.Println()
+	fmt.Println("Scheduling waves (tables within a wave run concurrently):")
+	for i, wave := range p.Waves {
+		fmt.Printf("  wave %d: %s\n", i+1, utils.JoinColumns(wave))
+	}
+}
+
+// checkpointRow is the subset of a checkpoint record needed to estimate
+// throughput; it deliberately ignores last_key, which plan doesn't need.
+type checkpointRow struct {
+	TableName  string
+	RowsCopied int64
+	UpdatedAt  time.Time
+}
+
+func listCheckpoints(ctx context.Context, db utils.Querier, checkpointTable string) ([]checkpointRow, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT table_name, rows_copied, updated_at FROM %s", checkpointTable))
+	if err != nil {
+		return nil, fmt.Errorf("listing checkpoints from %s: %w", checkpointTable, err)
+	}
+	defer rows.Close()
+
+	checkpoints := make([]checkpointRow, 0)
+	for rows.Next() {
+		var checkpoint checkpointRow
+		if err := rows.Scan(&checkpoint.TableName, &checkpoint.RowsCopied, &checkpoint.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scanning checkpoint from %s: %w", checkpointTable, err)
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating checkpoints from %s: %w", checkpointTable, err)
+	}
+
+	return checkpoints, nil
+}
+
+// observedThroughput derives a rows/second rate from the last run's
+// checkpoint metadata, by dividing the rows copied across all checkpointed
+// tables by the time span between their earliest and latest update. It
+// reports ok=false when there isn't enough spread to derive a meaningful
+// rate, so the caller can fall back to DefaultPlanThroughput; that fallback
+// is a fixed constant and no Config field overrides it.
+func observedThroughput(checkpoints []checkpointRow) (rowsPerSecond float64, ok bool) {
+	if len(checkpoints) < 2 {
+		return 0, false
+	}
+
+	var totalRows int64
+	var earliest, latest time.Time
+	for i, checkpoint := range checkpoints {
+		totalRows += checkpoint.RowsCopied
+		if i == 0 || checkpoint.UpdatedAt.Before(earliest) {
+			earliest = checkpoint.UpdatedAt
+		}
+		if i == 0 || checkpoint.UpdatedAt.After(latest) {
+			latest = checkpoint.UpdatedAt
+		}
+	}
+
+	elapsed := latest.Sub(earliest)
+	if elapsed <= 0 {
+		return 0, false
+	}
+
+	return float64(totalRows) / elapsed.Seconds(), true
+}
+
+func dependenciesSatisfiable(table Table, known map[string]bool) bool {
+	for _, dep := range table.Dependencies {
+		if dep == table.Name {
+			continue
+		}
+		if !known[dep] {
+			return false
+		}
+	}
+	return true
+}
+
+// computeWaves runs utils.FilterTables over every configured table, starting
+// with none processed, to show the waves in which a run schedules them
+// without requiring a live migration run. It does not leave out tables that
+// a resumed run skips as already fully migrated, so a resumed run can
+// schedule some tables in earlier waves than shown here.
+func computeWaves(tables []Table) [][]string {
+	processed := make(map[string]bool)
+	waves := make([][]string, 0)
+
+	for len(processed) < len(tables) {
+		wave := utils.FilterTables(tables, processed)
+		if len(wave) == 0 {
+			// Remaining tables have unsatisfiable dependencies; stop rather
+			// than loop forever.
+			break
+		}
+
+		names := make([]string, len(wave))
+		for i, table := range wave {
+			names[i] = table.Name
+			processed[table.Name] = true
+		}
+		waves = append(waves, names)
+	}
+
+	return waves
+}
@@ -0,0 +1,114 @@
+package functions
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/primatime/database-migrator/config"
+)
+
+func TestComputeWavesSchedulesDependenciesBeforeDependents(t *testing.T) {
+	tables := []Table{
+		{Name: "orders", Dependencies: []string{"customers"}},
+		{Name: "customers"},
+		{Name: "order_items", Dependencies: []string{"orders"}},
+	}
+
+	waves := computeWaves(tables)
+
+	if len(waves) != 3 {
+		t.Fatalf("waves = %v, want 3 waves", waves)
+	}
+	if len(waves[0]) != 1 || waves[0][0] != "customers" {
+		t.Fatalf("wave 0 = %v, want [customers]", waves[0])
+	}
+	if len(waves[1]) != 1 || waves[1][0] != "orders" {
+		t.Fatalf("wave 1 = %v, want [orders]", waves[1])
+	}
+	if len(waves[2]) != 1 || waves[2][0] != "order_items" {
+		t.Fatalf("wave 2 = %v, want [order_items]", waves[2])
+	}
+}
+
+func TestComputeWavesGroupsIndependentTablesTogether(t *testing.T) {
+	tables := []Table{
+		{Name: "a"},
+		{Name: "b"},
+		{Name: "c", Dependencies: []string{"a", "b"}},
+	}
+
+	waves := computeWaves(tables)
+
+	if len(waves) != 2 {
+		t.Fatalf("waves = %v, want 2 waves", waves)
+	}
+	if len(waves[0]) != 2 {
+		t.Fatalf("wave 0 = %v, want both independent tables scheduled together", waves[0])
+	}
+}
+
+func TestComputeWavesStopsOnUnsatisfiableDependency(t *testing.T) {
+	tables := []Table{
+		{Name: "a", Dependencies: []string{"missing"}},
+	}
+
+	waves := computeWaves(tables)
+
+	if len(waves) != 0 {
+		t.Fatalf("waves = %v, want no waves when a dependency can never be satisfied", waves)
+	}
+}
+
+func TestDependenciesSatisfiableIgnoresSelfReference(t *testing.T) {
+	table := Table{Name: "a", Dependencies: []string{"a"}}
+
+	if !dependenciesSatisfiable(table, map[string]bool{"a": true}) {
+		t.Fatal("a self-referencing dependency should not block a table")
+	}
+}
+
+func TestDependenciesSatisfiableRequiresKnownDependency(t *testing.T) {
+	table := Table{Name: "a", Dependencies: []string{"b"}}
+
+	if dependenciesSatisfiable(table, map[string]bool{"a": true}) {
+		t.Fatal("expected an unknown dependency to be unsatisfiable")
+	}
+	if !dependenciesSatisfiable(table, map[string]bool{"a": true, "b": true}) {
+		t.Fatal("expected a known dependency to be satisfiable")
+	}
+}
+
+func TestObservedThroughputNeedsAtLeastTwoCheckpoints(t *testing.T) {
+	_, ok := observedThroughput([]checkpointRow{{TableName: "a", RowsCopied: 100, UpdatedAt: time.Unix(0, 0)}})
+	if ok {
+		t.Fatal("expected ok=false with fewer than two checkpoints")
+	}
+}
+
+func TestObservedThroughputDividesRowsByElapsedTime(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+	checkpoints := []checkpointRow{
+		{TableName: "a", RowsCopied: 100, UpdatedAt: start},
+		{TableName: "b", RowsCopied: 400, UpdatedAt: start.Add(10 * time.Second)},
+	}
+
+	rate, ok := observedThroughput(checkpoints)
+	if !ok {
+		t.Fatal("expected ok=true with two checkpoints spread over time")
+	}
+	if want := 50.0; rate != want {
+		t.Fatalf("rate = %v, want %v", rate, want)
+	}
+}
+
+func TestObservedThroughputRejectsZeroElapsed(t *testing.T) {
+	same := time.Unix(1_700_000_000, 0)
+	checkpoints := []checkpointRow{
+		{TableName: "a", RowsCopied: 100, UpdatedAt: same},
+		{TableName: "b", RowsCopied: 400, UpdatedAt: same},
+	}
+
+	if _, ok := observedThroughput(checkpoints); ok {
+		t.Fatal("expected ok=false when all checkpoints share one timestamp")
+	}
+}
@@ -1,14 +1,14 @@
 package functions
 
 import (
-	"database/sql"
+	"context"
 	"fmt"
-	"log"
 
 	. "github.com/primatime/database-migrator/config"
+	"github.com/primatime/database-migrator/utils"
 )
 
-func RecreateStructure(driver string, sourceDB *sql.DB, config Config, configPath string) {
+func RecreateStructure(ctx context.Context, driver string, sourceDB utils.Querier, config Config, configPath string) error {
 	schema := config.Source.Schema
 
 	var tablesQuery string
@@ -20,18 +20,17 @@ func RecreateStructure(driver string, sourceDB *sql.DB, config Config, configPat
 		tablesQuery = fmt.Sprintf("SELECT table_name FROM information_schema.tables WHERE table_schema = '%s'", schema)
 	}
 
-	tableRows, err := sourceDB.Query(tablesQuery)
+	tableRows, err := sourceDB.QueryContext(ctx, tablesQuery)
 	if err != nil {
-		log.Fatalf("Error fetching tables: %v", err)
+		return fmt.Errorf("fetching tables: %w", err)
 	}
 	defer tableRows.Close()
 
 	tables := make([]Table, 0)
 	for tableRows.Next() {
 		var tableName string
-		err := tableRows.Scan(&tableName)
-		if err != nil {
-			log.Fatalf("Error scanning table name: %v", err)
+		if err := tableRows.Scan(&tableName); err != nil {
+			return fmt.Errorf("scanning table name: %w", err)
 		}
 
 		// query all columns of the table
@@ -44,78 +43,140 @@ func RecreateStructure(driver string, sourceDB *sql.DB, config Config, configPat
 			columnsQuery = fmt.Sprintf("SELECT column_name FROM information_schema.columns WHERE table_name = '%s' AND table_schema = '%s'", tableName, schema)
 		}
 
-		columnRows, err := sourceDB.Query(columnsQuery)
+		columnRows, err := sourceDB.QueryContext(ctx, columnsQuery)
 		if err != nil {
-			log.Fatalf("Error fetching columns for table %s: %v", tableName, err)
+			return fmt.Errorf("fetching columns for table %s: %w", tableName, err)
 		}
 
 		columns := make([]string, 0)
 		for columnRows.Next() {
 			var columnName string
-			err := columnRows.Scan(&columnName)
-			if err != nil {
-				log.Fatalf("Error scanning column name: %v", err)
+			if err := columnRows.Scan(&columnName); err != nil {
+				columnRows.Close()
+				return fmt.Errorf("scanning column name: %w", err)
 			}
 			columns = append(columns, columnName)
 		}
+		columnRows.Close()
 
 		// query all dependencies (foreign key relations) of the table
 		var dependenciesQuery string
 		switch driver {
 		case "godror":
 			dependenciesQuery = fmt.Sprintf(`
-			SELECT 
+			SELECT
 				a.table_name
-			FROM 
+			FROM
 				all_constraints a
 			JOIN
 				all_constraints b
 			ON
 				b.r_constraint_name = a.constraint_name
-			WHERE 
+			WHERE
 				b.table_name = '%s' AND b.owner = UPPER('%s') AND b.constraint_type = 'R'
 		`, tableName, schema)
 		case "postgres":
 			dependenciesQuery = fmt.Sprintf(`
-			SELECT 
+			SELECT
 				ccu.table_name AS foreign_table_name
-			FROM 
-				information_schema.table_constraints AS tc 
-			JOIN 
+			FROM
+				information_schema.table_constraints AS tc
+			JOIN
 				information_schema.key_column_usage AS kcu
-			  ON 
+			  ON
 				tc.constraint_name = kcu.constraint_name
 			  AND tc.table_schema = kcu.table_schema
-			JOIN 
+			JOIN
 				information_schema.constraint_column_usage AS ccu
-			  ON 
+			  ON
 				ccu.constraint_name = tc.constraint_name
 			  AND ccu.table_schema = tc.table_schema
-			WHERE 
+			WHERE
 				tc.constraint_type = 'FOREIGN KEY' AND tc.table_name='%s' AND tc.table_schema = '%s'
 		`, tableName, schema)
 		}
-		dependencyRows, err := sourceDB.Query(dependenciesQuery)
+		dependencyRows, err := sourceDB.QueryContext(ctx, dependenciesQuery)
 		if err != nil {
-			log.Fatalf("Error fetching dependencies for table %s: %v", tableName, err)
+			return fmt.Errorf("fetching dependencies for table %s: %w", tableName, err)
 		}
 
 		dependencies := make([]string, 0)
 		for dependencyRows.Next() {
 			var dependencyName string
-			err := dependencyRows.Scan(&dependencyName)
-			if err != nil {
-				log.Fatalf("Error scanning dependency name: %v", err)
+			if err := dependencyRows.Scan(&dependencyName); err != nil {
+				dependencyRows.Close()
+				return fmt.Errorf("scanning dependency name: %w", err)
 			}
 			dependencies = append(dependencies, dependencyName)
 		}
+		dependencyRows.Close()
+
+		keyColumns, err := primaryKeyColumns(ctx, sourceDB, driver, schema, tableName)
+		if err != nil {
+			return err
+		}
+
+		tables = append(tables, Table{Name: tableName, Columns: columns, Dependencies: dependencies, KeyColumns: keyColumns})
+	}
 
-		tables = append(tables, Table{Name: tableName, Columns: columns, Dependencies: dependencies})
+	if err := tableRows.Err(); err != nil {
+		return fmt.Errorf("iterating through tables: %w", err)
 	}
 
 	config.Tables = tables
 
-	WriteConfig(configPath, config)
+	if err := WriteConfig(ctx, configPath, config); err != nil {
+		return err
+	}
 
 	fmt.Println("Config file created successfully.")
+	return nil
+}
+
+// primaryKeyColumns fetches the ordered primary key columns of a table, used
+// to drive keyset pagination during MigrateTable.
+func primaryKeyColumns(ctx context.Context, sourceDB utils.Querier, driver, schema, tableName string) ([]string, error) {
+	var query string
+
+	switch driver {
+	case "godror":
+		query = fmt.Sprintf(`
+			SELECT cols.column_name
+			FROM all_constraints cons
+			JOIN all_cons_columns cols
+			  ON cons.constraint_name = cols.constraint_name AND cons.owner = cols.owner
+			WHERE cons.table_name = '%s' AND cons.owner = UPPER('%s') AND cons.constraint_type = 'P'
+			ORDER BY cols.position
+		`, tableName, schema)
+	case "postgres":
+		query = fmt.Sprintf(`
+			SELECT kcu.column_name
+			FROM information_schema.table_constraints tc
+			JOIN information_schema.key_column_usage kcu
+			  ON tc.constraint_name = kcu.constraint_name AND tc.table_schema = kcu.table_schema
+			WHERE tc.constraint_type = 'PRIMARY KEY' AND tc.table_name = '%s' AND tc.table_schema = '%s'
+			ORDER BY kcu.ordinal_position
+		`, tableName, schema)
+	}
+
+	rows, err := sourceDB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("fetching primary key columns for table %s: %w", tableName, err)
+	}
+	defer rows.Close()
+
+	keyColumns := make([]string, 0)
+	for rows.Next() {
+		var columnName string
+		if err := rows.Scan(&columnName); err != nil {
+			return nil, fmt.Errorf("scanning primary key column for table %s: %w", tableName, err)
+		}
+		keyColumns = append(keyColumns, columnName)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating through primary key columns for table %s: %w", tableName, err)
+	}
+
+	return keyColumns, nil
 }
@@ -1,286 +1,174 @@
 package main
 
 import (
-	"database/sql"
-	"encoding/json"
+	"context"
 	"flag"
 	"fmt"
-	"io"
 	"log"
 	"os"
-	"strings"
+	"os/signal"
 	"sync"
-	"sync/atomic"
-	"time"
+	"syscall"
 
 	_ "github.com/godror/godror"
 	_ "github.com/lib/pq"
-)
-
-type Config struct {
-	Source    DatabaseConfig `json:"source"`
-	Target    DatabaseConfig `json:"target"`
-	BatchSize int            `json:"batchSize"`
-	Tables    []Table        `json:"tables"`
-}
 
-type DatabaseConfig struct {
-	DSN    string `json:"dsn"`
-	Schema string `json:"schema"`
-	Driver string `json:"driver"`
-}
-
-type Table struct {
-	Name         string   `json:"name"`
-	Columns      []string `json:"columns"`
-	Dependencies []string `json:"dependencies"`
-}
+	. "github.com/primatime/database-migrator/config"
+	"github.com/primatime/database-migrator/functions"
+	"github.com/primatime/database-migrator/migrations"
+	"github.com/primatime/database-migrator/progress"
+	"github.com/primatime/database-migrator/utils"
+)
 
 func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	var configPath string
+	var migrateCmd string
+	var migrationsDir string
+	var migrateSteps int
+	var resume bool
+	var restart bool
+	var mode string
 	flag.StringVar(&configPath, "config", "config.json", "Path to the configuration file")
+	flag.StringVar(&migrateCmd, "migrate", "", "Run a schema migration command against the target database (up|down|status)")
+	flag.StringVar(&migrationsDir, "migrationsDir", "migrations", "Directory containing NNNN_name.up.sql / NNNN_name.down.sql files")
+	flag.IntVar(&migrateSteps, "migrateSteps", 0, "Number of migrations to apply/roll back (0 means all)")
+	flag.BoolVar(&resume, "resume", true, "Resume table migrations from their last recorded checkpoint")
+	flag.BoolVar(&restart, "restart", false, "Discard existing checkpoints and migrate every table from scratch")
+	flag.StringVar(&mode, "mode", "migrate", "What to do: migrate (copy data) or plan (print a dry-run migration plan and exit)")
 	flag.Parse()
 
 	if configPath == "" {
 		log.Fatal("Configuration file path must be provided")
 	}
 
-	config, err := readConfig(configPath)
+	config, err := ReadConfig(ctx, configPath)
 	if err != nil {
 		log.Fatalf("Error reading config file: %v", err)
 	}
 
 	// Connect to source database
-	sourceDB, err := sql.Open(config.Source.Driver, config.Source.DSN)
+	sourceConn, err := utils.OpenDB(config.Source.Driver, config.Source.DSN, config.Timeouts.StatementTimeout.Duration())
 	if err != nil {
 		log.Fatalf("Source connection error: %v", err)
 	}
-	defer sourceDB.Close()
+	defer sourceConn.Close()
+	sourceDB := utils.NewLoggedDB(sourceConn, config.Timeouts.SlowQueryThreshold.Duration())
 
 	// Connect to target database
-	targetDB, err := sql.Open(config.Target.Driver, config.Target.DSN)
+	targetConn, err := utils.OpenDB(config.Target.Driver, config.Target.DSN, config.Timeouts.StatementTimeout.Duration())
 	if err != nil {
 		log.Fatalf("Target connection error: %v", err)
 	}
-	defer targetDB.Close()
-
-	progress := make(map[string]*struct {
-		migrated int64
-		total    int64
-	})
-	for _, table := range config.Tables {
-		rowCount, err := getRowCount(sourceDB, table.Name, config.Source.Schema)
-
-		if err != nil {
-			log.Fatalf("Error getting row count for table %s: %v", table.Name, err)
-		}
+	defer targetConn.Close()
+	targetDB := utils.NewLoggedDB(targetConn, config.Timeouts.SlowQueryThreshold.Duration())
 
-		progress[table.Name] = &struct {
-			migrated int64
-			total    int64
-		}{
-			migrated: 0,
-			total:    rowCount,
+	if migrateCmd != "" {
+		if err := runMigrationCommand(ctx, migrateCmd, targetDB, config, migrationsDir, migrateSteps); err != nil {
+			log.Fatalf("Error running schema migration %s: %v", migrateCmd, err)
 		}
+		return
 	}
 
-	processedTables := make(map[string]bool)
-	var wg sync.WaitGroup
-
-	for len(processedTables) < len(config.Tables) {
-		tablesToProcess := filterTables(config.Tables, processedTables)
-
-		for _, table := range tablesToProcess {
-			wg.Add(1)
-			go func(table Table) {
-				defer wg.Done()
-				migrateTable(sourceDB, targetDB, table, config.BatchSize, progress, config.Source.Driver, config.Source.Schema, config.Target.Schema)
-			}(table)
-
-			processedTables[table.Name] = true
-		}
-
-		wg.Wait()
+	checkpointTable := config.CheckpointTable
+	if checkpointTable == "" {
+		checkpointTable = functions.DefaultCheckpointTable
 	}
 
-	fmt.Println("Data migration complete.")
-}
-
-func filterTables(tables []Table, processedTables map[string]bool) []Table {
-	result := make([]Table, 0)
-
-	for _, table := range tables {
-		if processedTables[table.Name] {
-			continue
-		}
-
-		ready := true
-		for _, dep := range table.Dependencies {
-			if !processedTables[dep] {
-				ready = false
-				break
-			}
+	switch mode {
+	case "migrate":
+		// falls through to the migration run below
+	case "plan":
+		plan, err := functions.BuildPlan(ctx, sourceDB, targetDB, config, checkpointTable)
+		if err != nil {
+			log.Fatalf("Error building migration plan: %v", err)
 		}
-
-		if ready {
-			result = append(result, table)
+		plan.Print()
+		if !plan.AllComplete() {
+			os.Exit(1)
 		}
+		return
+	default:
+		log.Fatalf("unknown -mode %q (expected migrate or plan)", mode)
 	}
 
-	return result
-}
-
-func readConfig(configFile string) (Config, error) {
-	config := Config{}
-	file, err := os.Open(configFile)
-	if err != nil {
-		return config, err
-	}
-	defer file.Close()
-
-	bytes, err := io.ReadAll(file)
-	if err != nil {
-		return config, err
-	}
-
-	err = json.Unmarshal(bytes, &config)
-	return config, err
-}
-
-func migrateTable(sourceDB, targetDB *sql.DB, table Table, batchSize int, progress map[string]*struct {
-	migrated int64
-	total    int64
-}, sourceDriver string, sourceSchema string, targetSchema string) {
-	columns := table.Columns
-	columnsJoined := joinColumns(columns)
-
-	startTime := time.Now()
-
-	// Prepare the insert statement for target database
-	insertStmt := fmt.Sprintf("INSERT INTO %s.%s (%s) VALUES", targetSchema, table.Name, columnsJoined) + " %s"
-
-	// Start progress display for this table
-	progressTicker := time.NewTicker(1 * time.Second)
-	defer progressTicker.Stop()
-
-	go func() {
-		for range progressTicker.C {
-			printProgress(table.Name, progress, startTime)
+	if restart {
+		if err := functions.ResetCheckpoints(ctx, targetDB, config.Target.Driver, checkpointTable); err != nil {
+			log.Fatalf("Error resetting checkpoints: %v", err)
 		}
-	}()
-
-	var offset int64 = 0
-	for {
-		// Retrieve data from source using pagination
-		var query string
+	} else if err := functions.EnsureCheckpointTable(ctx, targetDB, config.Target.Driver, checkpointTable); err != nil {
+		log.Fatalf("Error preparing checkpoint table: %v", err)
+	}
 
-		switch sourceDriver {
-		case "godror":
-			query = fmt.Sprintf(`
-					SELECT %s FROM (
-						SELECT t.*, ROWNUM rnum FROM (
-							SELECT %s FROM %s.%s
-						) t
-						WHERE ROWNUM <= %d
-					)
-					WHERE rnum > %d`, columnsJoined, columnsJoined, sourceSchema, table.Name, offset+int64(batchSize), offset)
-		case "postgres":
-			query = fmt.Sprintf(`
-					SELECT %s FROM (
-						SELECT t.*, ROW_NUMBER() OVER () AS rnum FROM (
-							SELECT %s FROM %s.%s
-						) t
-					) AS subquery
-					WHERE rnum <= %d
-					OFFSET %d`, columnsJoined, columnsJoined, sourceSchema, table.Name, offset+int64(batchSize), offset)
-		}
+	progressData := make(progress.Data)
+	processedTables := make(map[string]bool)
 
-		rows, err := sourceDB.Query(query)
+	for _, table := range config.Tables {
+		rowCount, err := utils.GetRowCount(ctx, sourceDB, table.Name, config.Source.Schema)
 		if err != nil {
-			log.Fatalf("Error querying Source database for table %s: %v", table.Name, err)
+			log.Fatalf("Error getting row count for table %s: %v", table.Name, err)
 		}
 
-		values := make([]string, 0, len(columns)*batchSize)
-		rowBatch := 0
-
-		for rows.Next() {
-			columnValues := make([]interface{}, len(columns))
-			columnPointers := make([]interface{}, len(columns))
-
-			for i := range columns {
-				columnPointers[i] = &columnValues[i]
-			}
+		progressData[table.Name] = &progress.DataItem{Total: rowCount}
 
-			err = rows.Scan(columnPointers...)
+		if resume {
+			_, rowsCopied, found, err := functions.LoadCheckpoint(ctx, targetDB, config.Target.Driver, checkpointTable, table.Name)
 			if err != nil {
-				log.Fatalf("Error scanning row from table %s: %v", table.Name, err)
+				log.Fatalf("Error loading checkpoint for table %s: %v", table.Name, err)
 			}
-
-			stringValues := make([]string, 0)
-			for _, v := range columnValues {
-				stringValues = append(stringValues, fmt.Sprintf("'%s'", fmt.Sprint(v)))
+			if found && rowsCopied >= rowCount {
+				fmt.Printf("Table %s already fully migrated, skipping.\n", table.Name)
+				progressData[table.Name].Migrated = rowsCopied
+				processedTables[table.Name] = true
 			}
-
-			values = append(values, fmt.Sprintf("(%s)", joinColumns(stringValues)))
-			rowBatch++
 		}
+	}
 
-		if rowBatch > 0 {
-			insertQuery := fmt.Sprintf(insertStmt, strings.Join(values, ", "))
-			_, err = targetDB.Exec(insertQuery)
-			if err != nil {
-				log.Fatalf("Error inserting batch into table %s: %v", table.Name, err)
-			}
-
-			atomic.AddInt64(&progress[table.Name].migrated, int64(rowBatch))
-		}
+	var wg sync.WaitGroup
+	errs := make(chan error, len(config.Tables))
 
-		rows.Close()
+	for len(processedTables) < len(config.Tables) {
+		tablesToProcess := utils.FilterTables(config.Tables, processedTables)
 
-		if err = rows.Err(); err != nil {
-			log.Fatalf("Error iterating through rows for table %s: %v", table.Name, err)
-		}
+		for _, table := range tablesToProcess {
+			wg.Add(1)
+			go func(table Table) {
+				defer wg.Done()
+				if err := functions.MigrateTable(ctx, sourceDB, targetDB, table, config.BatchSize, progressData, config.Source.Driver, config.Source.Schema, config.Target.Driver, config.Target.Schema, checkpointTable, resume, config.Timeouts); err != nil {
+					errs <- fmt.Errorf("migrating table %s: %w", table.Name, err)
+				}
+			}(table)
 
-		if rowBatch < batchSize {
-			break
+			processedTables[table.Name] = true
 		}
 
-		offset += int64(batchSize)
+		wg.Wait()
 	}
+	close(errs)
 
-	progressTicker.Stop()                          // stop the progress ticker
-	printProgress(table.Name, progress, startTime) // print the final progress
-}
-
-func joinColumns(columns []string) string {
-	return strings.Join(columns, ", ")
-}
+	for err := range errs {
+		log.Printf("%v", err)
+	}
 
-func getRowCount(db *sql.DB, tableName string, schema string) (int64, error) {
-	var rowCount int64
-	err := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s.%s", schema, tableName)).Scan(&rowCount)
-	return rowCount, err
+	fmt.Println("Data migration complete.")
 }
 
-func printProgress(tableName string, progress map[string]*struct {
-	migrated int64
-	total    int64
-}, startTime time.Time) {
-	p := progress[tableName]
-	migratedRows := atomic.LoadInt64(&p.migrated)
-	remainingRows := p.total - migratedRows
-	completionPercentage := float64(migratedRows) / float64(p.total) * 100
-
-	if migratedRows != 0 {
-		elapsedTime := time.Since(startTime)
-		timePerRow := elapsedTime / time.Duration(migratedRows)
-		estimatedRemainingTime := time.Duration(remainingRows) * timePerRow
-
-		fmt.Printf("Data migration progress for table %s: %d/%d rows migrated (%.2f%%), Estimated time left: %v\n",
-			tableName, migratedRows, p.total, completionPercentage, estimatedRemainingTime)
-	} else {
-		fmt.Printf("Data migration progress for table %s: %d/%d rows migrated (%.2f%%)",
-			tableName, migratedRows, p.total, completionPercentage)
+func runMigrationCommand(ctx context.Context, cmd string, targetDB utils.ConnPool, config Config, migrationsDir string, steps int) error {
+	switch cmd {
+	case "up":
+		return migrations.Up(ctx, targetDB, config.Target.Driver, migrationsDir, config.Target.MigrationTable, steps)
+	case "down":
+		return migrations.Down(ctx, targetDB, config.Target.Driver, migrationsDir, config.Target.MigrationTable, steps)
+	case "status":
+		statuses, err := migrations.StatusOf(ctx, targetDB, config.Target.Driver, migrationsDir, config.Target.MigrationTable)
+		if err != nil {
+			return err
+		}
+		migrations.PrintStatus(statuses)
+		return nil
+	default:
+		return fmt.Errorf("unknown -migrate command %q (expected up, down or status)", cmd)
 	}
-
 }
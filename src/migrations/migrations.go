@@ -0,0 +1,384 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/primatime/database-migrator/utils"
+)
+
+// DefaultTable is the tracking table name used when a DatabaseConfig does
+// not set MigrationTable.
+const DefaultTable = "schema_migrations"
+
+// noTransactionDirective marks a migration file that must run outside of a
+// transaction (e.g. CREATE INDEX CONCURRENTLY on Postgres).
+const noTransactionDirective = "-- migrate:no-transaction"
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_[^.]+\.(up|down)\.sql$`)
+
+// Migration is a single versioned schema change, parsed from a pair of
+// NNNN_name.up.sql / NNNN_name.down.sql files.
+type Migration struct {
+	Sequence int64
+	Name     string
+	UpSQL    string
+	DownSQL  string
+}
+
+// Status describes whether a migration has been applied to the target
+// database.
+type Status struct {
+	Sequence  int64
+	Name      string
+	AppliedAt *time.Time
+}
+
+// LoadDir parses every NNNN_name.up.sql / NNNN_name.down.sql pair found in
+// dir and returns them sorted by Sequence.
+func LoadDir(dir string) ([]Migration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading migrations directory %s: %w", dir, err)
+	}
+
+	byName := make(map[string]*Migration)
+	order := make([]string, 0)
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		matches := fileNamePattern.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			continue
+		}
+
+		sequence, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing sequence from %s: %w", entry.Name(), err)
+		}
+
+		name := strings.TrimSuffix(strings.TrimPrefix(entry.Name(), matches[1]+"_"), "."+matches[2]+".sql")
+
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("reading migration file %s: %w", entry.Name(), err)
+		}
+
+		key := matches[1] + "_" + name
+		migration, ok := byName[key]
+		if !ok {
+			migration = &Migration{Sequence: sequence, Name: name}
+			byName[key] = migration
+			order = append(order, key)
+		}
+
+		if matches[2] == "up" {
+			migration.UpSQL = string(content)
+		} else {
+			migration.DownSQL = string(content)
+		}
+	}
+
+	result := make([]Migration, 0, len(order))
+	for _, key := range order {
+		result = append(result, *byName[key])
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Sequence < result[j].Sequence
+	})
+
+	return result, nil
+}
+
+// EnsureTable creates the tracking table if it does not already exist.
+func EnsureTable(ctx context.Context, db utils.TxQuerier, driver, table string) error {
+	var ddl string
+
+	switch driver {
+	case "godror":
+		ddl = fmt.Sprintf(`
+			BEGIN
+				EXECUTE IMMEDIATE 'CREATE TABLE %s (
+					version NUMBER(19) PRIMARY KEY,
+					name VARCHAR2(255),
+					applied_at TIMESTAMP
+				)';
+			EXCEPTION
+				WHEN OTHERS THEN
+					IF SQLCODE != -955 THEN
+						RAISE;
+					END IF;
+			END;`, table)
+	case "postgres":
+		ddl = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			version BIGINT PRIMARY KEY,
+			name TEXT,
+			applied_at TIMESTAMP
+		)`, table)
+	default:
+		return fmt.Errorf("unsupported driver %q", driver)
+	}
+
+	if _, err := db.ExecContext(ctx, ddl); err != nil {
+		return fmt.Errorf("creating tracking table %s: %w", table, err)
+	}
+
+	return nil
+}
+
+// lock acquires a per-driver advisory lock so that two runners can't apply
+// migrations concurrently, and returns a function that releases it.
+func lock(ctx context.Context, db utils.TxQuerier, driver string) (func(), error) {
+	const lockID = 727472 // arbitrary constant identifying this tool's lock
+
+	switch driver {
+	case "postgres":
+		if _, err := db.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockID); err != nil {
+			return nil, fmt.Errorf("acquiring advisory lock: %w", err)
+		}
+		return func() {
+			db.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lockID)
+		}, nil
+	case "godror":
+		var result int
+		if _, err := db.ExecContext(ctx, "BEGIN :result := DBMS_LOCK.REQUEST(:id, DBMS_LOCK.X_MODE); END;", sql.Named("result", sql.Out{Dest: &result}), sql.Named("id", lockID)); err != nil {
+			return nil, fmt.Errorf("acquiring advisory lock: %w", err)
+		}
+		return func() {
+			db.ExecContext(ctx, "DECLARE released INTEGER; BEGIN released := DBMS_LOCK.RELEASE(:id); END;", lockID)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported driver %q", driver)
+	}
+}
+
+func applied(ctx context.Context, db utils.TxQuerier, table string) (map[int64]time.Time, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT version, applied_at FROM %s", table))
+	if err != nil {
+		return nil, fmt.Errorf("reading applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("scanning applied migration: %w", err)
+		}
+		result[version] = appliedAt
+	}
+
+	return result, rows.Err()
+}
+
+func runMigration(ctx context.Context, db utils.TxQuerier, sqlText string) error {
+	noTx := strings.HasPrefix(strings.TrimSpace(sqlText), noTransactionDirective)
+	sqlText = strings.TrimPrefix(strings.TrimSpace(sqlText), noTransactionDirective)
+
+	if noTx {
+		_, err := db.ExecContext(ctx, sqlText)
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, sqlText); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Up applies up to n pending migrations (all of them when n <= 0) from dir,
+// in sequence order.
+func Up(ctx context.Context, db utils.ConnPool, driver, dir, table string, n int) error {
+	if table == "" {
+		table = DefaultTable
+	}
+
+	// Pin a single physical connection for the lock and every migration it
+	// guards: db is a pool, and nothing guarantees a bare ExecContext against
+	// it keeps landing on the connection that's holding the advisory lock.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("acquiring dedicated connection: %w", err)
+	}
+	defer conn.Close()
+
+	if err := EnsureTable(ctx, conn, driver, table); err != nil {
+		return err
+	}
+
+	unlock, err := lock(ctx, conn, driver)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	migrations, err := LoadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	done, err := applied(ctx, conn, table)
+	if err != nil {
+		return err
+	}
+
+	applyCount := 0
+	for _, migration := range migrations {
+		if _, ok := done[migration.Sequence]; ok {
+			continue
+		}
+
+		if n > 0 && applyCount >= n {
+			break
+		}
+
+		if err := runMigration(ctx, conn, migration.UpSQL); err != nil {
+			return fmt.Errorf("applying migration %d_%s: %w", migration.Sequence, migration.Name, err)
+		}
+
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (version, name, applied_at) VALUES (%s, %s, %s)", table, placeholder(driver, 1), placeholder(driver, 2), placeholder(driver, 3)),
+			migration.Sequence, migration.Name, time.Now().UTC()); err != nil {
+			return fmt.Errorf("recording migration %d_%s: %w", migration.Sequence, migration.Name, err)
+		}
+
+		fmt.Printf("applied migration %d_%s\n", migration.Sequence, migration.Name)
+		applyCount++
+	}
+
+	return nil
+}
+
+// Down rolls back up to n of the most recently applied migrations (all of
+// them when n <= 0) from dir, in reverse sequence order.
+func Down(ctx context.Context, db utils.ConnPool, driver, dir, table string, n int) error {
+	if table == "" {
+		table = DefaultTable
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("acquiring dedicated connection: %w", err)
+	}
+	defer conn.Close()
+
+	if err := EnsureTable(ctx, conn, driver, table); err != nil {
+		return err
+	}
+
+	unlock, err := lock(ctx, conn, driver)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	migrations, err := LoadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	done, err := applied(ctx, conn, table)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Sequence > migrations[j].Sequence
+	})
+
+	rollbackCount := 0
+	for _, migration := range migrations {
+		if _, ok := done[migration.Sequence]; !ok {
+			continue
+		}
+
+		if n > 0 && rollbackCount >= n {
+			break
+		}
+
+		if err := runMigration(ctx, conn, migration.DownSQL); err != nil {
+			return fmt.Errorf("rolling back migration %d_%s: %w", migration.Sequence, migration.Name, err)
+		}
+
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE version = %s", table, placeholder(driver, 1)), migration.Sequence); err != nil {
+			return fmt.Errorf("removing migration record %d_%s: %w", migration.Sequence, migration.Name, err)
+		}
+
+		fmt.Printf("rolled back migration %d_%s\n", migration.Sequence, migration.Name)
+		rollbackCount++
+	}
+
+	return nil
+}
+
+// StatusOf reports, for every migration found in dir, whether and when it
+// was applied to the target database.
+func StatusOf(ctx context.Context, db utils.TxQuerier, driver, dir, table string) ([]Status, error) {
+	if table == "" {
+		table = DefaultTable
+	}
+
+	if err := EnsureTable(ctx, db, driver, table); err != nil {
+		return nil, err
+	}
+
+	migrations, err := LoadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	done, err := applied(ctx, db, table)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Status, 0, len(migrations))
+	for _, migration := range migrations {
+		entry := Status{Sequence: migration.Sequence, Name: migration.Name}
+		if appliedAt, ok := done[migration.Sequence]; ok {
+			t := appliedAt
+			entry.AppliedAt = &t
+		}
+		result = append(result, entry)
+	}
+
+	return result, nil
+}
+
+// PrintStatus writes a simple table of migration status to stdout.
+func PrintStatus(statuses []Status) {
+	fmt.Printf("%-10s %-40s %s\n", "SEQUENCE", "NAME", "APPLIED AT")
+	for _, s := range statuses {
+		appliedAt := "pending"
+		if s.AppliedAt != nil {
+			appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%-10d %-40s %s\n", s.Sequence, s.Name, appliedAt)
+	}
+}
+
+func placeholder(driver string, pos int) string {
+	if driver == "postgres" {
+		return fmt.Sprintf("$%d", pos)
+	}
+	return ":" + strconv.Itoa(pos)
+}
@@ -0,0 +1,84 @@
+package migrations
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeMigrationFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("writing %s: %v", name, err)
+		}
+	}
+}
+
+func TestLoadDirPairsUpAndDownFilesBySequenceAndName(t *testing.T) {
+	dir := t.TempDir()
+	writeMigrationFiles(t, dir, map[string]string{
+		"0002_add_index.up.sql":      "CREATE INDEX idx ON t (a)",
+		"0002_add_index.down.sql":    "DROP INDEX idx",
+		"0001_create_table.up.sql":   "CREATE TABLE t (a INT)",
+		"0001_create_table.down.sql": "DROP TABLE t",
+	})
+
+	migrations, err := LoadDir(dir)
+	if err != nil {
+		t.Fatalf("LoadDir: %v", err)
+	}
+
+	if len(migrations) != 2 {
+		t.Fatalf("got %d migrations, want 2", len(migrations))
+	}
+
+	if migrations[0].Sequence != 1 || migrations[0].Name != "create_table" {
+		t.Fatalf("migrations[0] = %+v, want sequence 1 create_table", migrations[0])
+	}
+	if migrations[0].UpSQL != "CREATE TABLE t (a INT)" || migrations[0].DownSQL != "DROP TABLE t" {
+		t.Fatalf("migrations[0] SQL not loaded correctly: %+v", migrations[0])
+	}
+
+	if migrations[1].Sequence != 2 || migrations[1].Name != "add_index" {
+		t.Fatalf("migrations[1] = %+v, want sequence 2 add_index", migrations[1])
+	}
+}
+
+func TestLoadDirIgnoresUnrelatedFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeMigrationFiles(t, dir, map[string]string{
+		"0001_create_table.up.sql":   "CREATE TABLE t (a INT)",
+		"0001_create_table.down.sql": "DROP TABLE t",
+		"README.md":                  "not a migration",
+		"0001_create_table.sql":      "missing the up/down suffix",
+	})
+
+	migrations, err := LoadDir(dir)
+	if err != nil {
+		t.Fatalf("LoadDir: %v", err)
+	}
+
+	if len(migrations) != 1 {
+		t.Fatalf("got %d migrations, want 1 (unrelated files should be skipped): %+v", len(migrations), migrations)
+	}
+}
+
+func TestLoadDirSortsBySequenceNotFilename(t *testing.T) {
+	dir := t.TempDir()
+	writeMigrationFiles(t, dir, map[string]string{
+		"10_later.up.sql":    "later up",
+		"10_later.down.sql":  "later down",
+		"2_earlier.up.sql":   "earlier up",
+		"2_earlier.down.sql": "earlier down",
+	})
+
+	migrations, err := LoadDir(dir)
+	if err != nil {
+		t.Fatalf("LoadDir: %v", err)
+	}
+
+	if len(migrations) != 2 || migrations[0].Name != "earlier" || migrations[1].Name != "later" {
+		t.Fatalf("migrations not sorted by sequence: %+v", migrations)
+	}
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+// statementTimeoutSQL renders the session-level statement that must run on
+// every physical connection when StatementTimeout is set.
+func statementTimeoutSQL(dbDriver string, timeout time.Duration) (string, error) {
+	switch dbDriver {
+	case "postgres":
+		return fmt.Sprintf("SET statement_timeout = %d", timeout.Milliseconds()), nil
+	case "godror":
+		return fmt.Sprintf("ALTER SESSION SET CALL_TIMEOUT = %d", timeout.Milliseconds()), nil
+	default:
+		return "", fmt.Errorf("unsupported driver %q", dbDriver)
+	}
+}
+
+// timeoutConnector wraps a driver.Connector so that setupSQL runs on every
+// physical connection the pool opens, before sql.DB can hand that connection
+// to a caller. Running the same statement once via ExecContext against an
+// already-open *sql.DB only reaches whichever single connection happens to
+// service that call — under concurrent use (e.g. MigrateTable's one
+// goroutine per table within a wave) most connections the pool opens later
+// never see it, so the timeout silently stops applying.
+type timeoutConnector struct {
+	driver.Connector
+	setupSQL string
+}
+
+func (c *timeoutConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	execer, ok := conn.(driver.ExecerContext)
+	if !ok {
+		conn.Close()
+		return nil, fmt.Errorf("driver connection does not support ExecerContext, required to apply statementTimeout")
+	}
+
+	if _, err := execer.ExecContext(ctx, c.setupSQL, nil); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("applying statement timeout to new connection: %w", err)
+	}
+
+	return conn, nil
+}
+
+// OpenDB opens a connection pool for driverName/dsn. When timeout is
+// positive, every physical connection the pool ever creates has the
+// driver's server-side statement timeout applied before it is handed out,
+// so the limit holds even once callers pull multiple connections out of the
+// same pool concurrently. When timeout is zero, this is just sql.Open.
+func OpenDB(driverName, dsn string, timeout time.Duration) (*sql.DB, error) {
+	if timeout <= 0 {
+		return sql.Open(driverName, dsn)
+	}
+
+	setupSQL, err := statementTimeoutSQL(driverName, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer base.Close()
+
+	driverCtx, ok := base.Driver().(driver.DriverContext)
+	if !ok {
+		return nil, fmt.Errorf("driver %q does not support per-connection setup required for statementTimeout", driverName)
+	}
+
+	connector, err := driverCtx.OpenConnector(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("opening connector for statementTimeout: %w", err)
+	}
+
+	return sql.OpenDB(&timeoutConnector{Connector: connector, setupSQL: setupSQL}), nil
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+)
+
+// Querier is satisfied by both *sql.DB and *LoggedDB, letting callers accept
+// either a raw connection pool or one that logs slow queries.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// TxQuerier is a Querier that can also start transactions, which the
+// Postgres and Oracle loaders need to bulk-load a batch atomically.
+type TxQuerier interface {
+	Querier
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// ConnPool is a TxQuerier that can also hand out a single pinned physical
+// connection. Schema migrations need one: an advisory/DBMS_LOCK lock and the
+// DDL it guards must run over the same connection, which nothing guarantees
+// if each call instead goes through the shared pool. *sql.DB and *LoggedDB
+// both satisfy it.
+type ConnPool interface {
+	TxQuerier
+	Conn(ctx context.Context) (*sql.Conn, error)
+}
+
+// LoggedDB wraps a *sql.DB so that every ExecContext, QueryContext and
+// QueryRowContext call logs its SQL and elapsed time whenever it runs longer
+// than SlowQueryThreshold. Statements run inside a transaction from BeginTx
+// or on a connection from Conn are not timed or logged.
+type LoggedDB struct {
+	*sql.DB
+	SlowQueryThreshold time.Duration
+}
+
+func NewLoggedDB(db *sql.DB, slowQueryThreshold time.Duration) *LoggedDB {
+	return &LoggedDB{DB: db, SlowQueryThreshold: slowQueryThreshold}
+}
+
+func (l *LoggedDB) logIfSlow(query string, start time.Time) {
+	elapsed := time.Since(start)
+	if l.SlowQueryThreshold > 0 && elapsed > l.SlowQueryThreshold {
+		log.Printf("slow query (%v > %v): %s", elapsed, l.SlowQueryThreshold, query)
+	}
+}
+
+func (l *LoggedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	result, err := l.DB.ExecContext(ctx, query, args...)
+	l.logIfSlow(query, start)
+	return result, err
+}
+
+func (l *LoggedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := l.DB.QueryContext(ctx, query, args...)
+	l.logIfSlow(query, start)
+	return rows, err
+}
+
+func (l *LoggedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := l.DB.QueryRowContext(ctx, query, args...)
+	l.logIfSlow(query, start)
+	return row
+}
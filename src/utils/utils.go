@@ -1,7 +1,7 @@
 package utils
 
 import (
-	"database/sql"
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -38,10 +38,13 @@ func FilterTables(tables []Table, processedTables map[string]bool) []Table {
 	return result
 }
 
-func GetRowCount(db *sql.DB, tableName string, schema string) (int64, error) {
+func GetRowCount(ctx context.Context, db Querier, tableName string, schema string) (int64, error) {
 	var rowCount int64
-	err := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s.%s", schema, tableName)).Scan(&rowCount)
-	return rowCount, err
+	err := db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s.%s", schema, tableName)).Scan(&rowCount)
+	if err != nil {
+		return 0, fmt.Errorf("counting rows in %s.%s: %w", schema, tableName, err)
+	}
+	return rowCount, nil
 }
 
 func JoinColumns(columns []string) string {